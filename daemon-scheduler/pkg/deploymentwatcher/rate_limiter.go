@@ -0,0 +1,67 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package deploymentwatcher
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token-bucket limiter shared across every deployment a
+// Watcher is reconciling, so that DescribeTasks/DescribeContainerInstances
+// calls issued on their behalf never exceed qps in aggregate.
+type rateLimiter struct {
+	mu         sync.Mutex
+	qps        float64
+	tokens     float64
+	lastRefill time.Time
+	now        func() time.Time
+}
+
+// newRateLimiter creates a limiter that starts with a full bucket of qps
+// tokens, refilling at qps tokens per second up to that same burst size.
+func newRateLimiter(qps float64) *rateLimiter {
+	return &rateLimiter{
+		qps:        qps,
+		tokens:     qps,
+		lastRefill: time.Now(),
+		now:        time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed right now, consuming a token if
+// so. It never blocks.
+func (r *rateLimiter) Allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	elapsed := now.Sub(r.lastRefill).Seconds()
+	r.tokens = math.Min(r.qps, r.tokens+elapsed*r.qps)
+	r.lastRefill = now
+
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
+
+// wait blocks until a token is available.
+func (r *rateLimiter) wait() {
+	for !r.Allow() {
+		time.Sleep(time.Millisecond)
+	}
+}
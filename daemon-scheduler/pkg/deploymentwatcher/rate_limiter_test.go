@@ -0,0 +1,49 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package deploymentwatcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiterBurstDoesNotExceedQPS(t *testing.T) {
+	qps := 10.0
+	limiter := newRateLimiter(qps)
+
+	allowed := 0
+	for i := 0; i < 100; i++ {
+		if limiter.Allow() {
+			allowed++
+		}
+	}
+
+	assert.True(t, float64(allowed) <= qps, "a burst of 100 calls with no elapsed time should not exceed the configured QPS of %v, got %d", qps, allowed)
+}
+
+func TestRateLimiterRefillsOverTime(t *testing.T) {
+	qps := 10.0
+	limiter := newRateLimiter(qps)
+	now := limiter.lastRefill
+	limiter.now = func() time.Time { return now }
+
+	for limiter.Allow() {
+	}
+	assert.False(t, limiter.Allow(), "bucket should be exhausted after draining all initial tokens")
+
+	now = now.Add(time.Second)
+	assert.True(t, limiter.Allow(), "bucket should refill after a full second has elapsed")
+}
@@ -0,0 +1,63 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package deploymentwatcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/blox/blox/daemon-scheduler/pkg/types"
+)
+
+// updateBatcher coalesces deployment status writes so that multiple
+// deployments settling within the same batchDur window are flushed to the
+// backend together instead of one write per deployment.
+type updateBatcher struct {
+	mu       sync.Mutex
+	batchDur time.Duration
+	pending  []*types.Deployment
+	timer    *time.Timer
+	flush    func([]*types.Deployment)
+}
+
+func newUpdateBatcher(batchDur time.Duration, flush func([]*types.Deployment)) *updateBatcher {
+	return &updateBatcher{
+		batchDur: batchDur,
+		flush:    flush,
+	}
+}
+
+// add queues d for the next flush, starting the batch window if this is the
+// first pending update since the last flush.
+func (b *updateBatcher) add(d *types.Deployment) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.pending = append(b.pending, d)
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.batchDur, b.drain)
+	}
+}
+
+func (b *updateBatcher) drain() {
+	b.mu.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.timer = nil
+	b.mu.Unlock()
+
+	if len(pending) > 0 {
+		b.flush(pending)
+	}
+}
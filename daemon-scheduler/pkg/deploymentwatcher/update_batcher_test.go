@@ -0,0 +1,72 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package deploymentwatcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blox/blox/daemon-scheduler/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateBatcherCoalescesWithinWindow(t *testing.T) {
+	var mu sync.Mutex
+	var flushes [][]*types.Deployment
+
+	batcher := newUpdateBatcher(50*time.Millisecond, func(batch []*types.Deployment) {
+		mu.Lock()
+		defer mu.Unlock()
+		flushes = append(flushes, batch)
+	})
+
+	batcher.add(&types.Deployment{ID: "one"})
+	batcher.add(&types.Deployment{ID: "two"})
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(flushes) == 1
+	}, time.Second, 5*time.Millisecond, "expected a single batched flush within batchDur")
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, flushes[0], 2, "both deployments added within the window should arrive in the same flush")
+}
+
+func TestUpdateBatcherStartsNewWindowAfterDrain(t *testing.T) {
+	var mu sync.Mutex
+	flushCount := 0
+
+	batcher := newUpdateBatcher(20*time.Millisecond, func(batch []*types.Deployment) {
+		mu.Lock()
+		defer mu.Unlock()
+		flushCount++
+	})
+
+	batcher.add(&types.Deployment{ID: "one"})
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return flushCount == 1
+	}, time.Second, 5*time.Millisecond, "expected the first window to flush")
+
+	batcher.add(&types.Deployment{ID: "two"})
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return flushCount == 2
+	}, time.Second, 5*time.Millisecond, "expected a second window to flush independently")
+}
@@ -0,0 +1,208 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package deploymentwatcher observes in-flight deployments and reconciles
+// them against ECS state on a bounded schedule, so that callers don't need
+// to poll DescribeTasks/DescribeContainerInstances themselves.
+package deploymentwatcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/blox/blox/daemon-scheduler/pkg/types"
+)
+
+// defaultPollInterval is how often each watched deployment's goroutine
+// checks whether it is due for another reconciliation pass, independent of
+// the shared QPS budget enforced by the rate limiter.
+const defaultPollInterval = 500 * time.Millisecond
+
+// Backend is the subset of the ECS-backed facade and deployment store the
+// watcher needs to reconcile deployments and persist status updates.
+type Backend interface {
+	DescribeTasks(cluster string, taskArns []string) ([]*ecs.Task, []*ecs.Failure, error)
+	DescribeContainerInstances(cluster string, instanceArns []string) ([]*ecs.ContainerInstance, []*ecs.Failure, error)
+	UpdateDeployment(deployment *types.Deployment) error
+}
+
+// Watcher reconciles in-flight deployments against ECS state, rate-limiting
+// the describe calls it issues across all deployments and coalescing
+// backend writes into batches.
+type Watcher struct {
+	backend Backend
+	limiter *rateLimiter
+	batcher *updateBatcher
+
+	// pollInterval is overridable so tests don't have to wait out the
+	// production interval.
+	pollInterval time.Duration
+
+	mu      sync.Mutex
+	entries map[string]chan struct{} // deployment ID -> stop channel
+	enabled bool
+}
+
+// NewDeploymentsWatcher creates a Watcher. qps caps the combined
+// DescribeTasks/DescribeContainerInstances call rate across all watched
+// deployments; batchDur coalesces backend writes so that deployments
+// completing within the same window generate a single write.
+func NewDeploymentsWatcher(backend Backend, qps float64, batchDur time.Duration) *Watcher {
+	w := &Watcher{
+		backend:      backend,
+		limiter:      newRateLimiter(qps),
+		pollInterval: defaultPollInterval,
+		entries:      make(map[string]chan struct{}),
+		enabled:      true,
+	}
+	w.batcher = newUpdateBatcher(batchDur, w.flushUpdates)
+	return w
+}
+
+// Watch starts reconciling d on its own goroutine, fed by the watcher's
+// shared rate limiter and batcher. Watching a deployment that is already
+// watched is a no-op.
+func (w *Watcher) Watch(d *types.Deployment) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, ok := w.entries[d.ID]; ok {
+		return
+	}
+
+	stop := make(chan struct{})
+	w.entries[d.ID] = stop
+	go w.run(d, stop)
+}
+
+// Remove stops watching the deployment with the given ID, if any. Its
+// goroutine exits within one poll cycle of the stop channel closing.
+func (w *Watcher) Remove(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if stop, ok := w.entries[id]; ok {
+		close(stop)
+		delete(w.entries, id)
+	}
+}
+
+// SetEnabled toggles reconciliation for all watched deployments without
+// tearing down their goroutines. Disabling does not stop the shared
+// limiter or batcher from serving deployments that are already mid-flush.
+func (w *Watcher) SetEnabled(enabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.enabled = enabled
+}
+
+func (w *Watcher) isEnabled() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enabled
+}
+
+func (w *Watcher) run(d *types.Deployment, stop chan struct{}) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !w.isEnabled() {
+				continue
+			}
+			if d.CurrentStatus() == types.DeploymentCompleted {
+				w.Remove(d.ID)
+				return
+			}
+			w.reconcile(d)
+		}
+	}
+}
+
+// reconcile fetches the current ECS state of d's tasks and container
+// instances, subject to the shared rate limit, and drives the deployment's
+// status transitions from the result before queuing it for its next batched
+// write.
+func (w *Watcher) reconcile(d *types.Deployment) {
+	cluster, taskArns, desiredTaskCount := d.ReconciliationTarget()
+
+	w.limiter.wait()
+	tasks, failures, err := w.backend.DescribeTasks(cluster, taskArns)
+	if err != nil {
+		return
+	}
+
+	if instanceArns := containerInstanceArns(tasks); len(instanceArns) > 0 {
+		w.limiter.wait()
+		_, instanceFailures, err := w.backend.DescribeContainerInstances(cluster, instanceArns)
+		if err != nil {
+			return
+		}
+		failures = append(failures, instanceFailures...)
+	}
+
+	if due := d.DueForRetry(time.Now()); len(due) > 0 {
+		failures = append(failures, due...)
+	}
+
+	if runningTaskCount(tasks) >= desiredTaskCount && len(failures) == 0 {
+		d.UpdateDeploymentToCompleted(failures)
+	} else {
+		d.UpdateDeploymentToInProgress(desiredTaskCount, failures, nil)
+	}
+
+	// Checked after the status update, which sets Progressing back to True.
+	d.CheckProgressDeadline(time.Now())
+
+	w.batcher.add(d)
+}
+
+// containerInstanceArns collects the distinct container instance ARNs that
+// tasks are running on, so their health can be described in the same
+// reconciliation pass.
+func containerInstanceArns(tasks []*ecs.Task) []string {
+	seen := make(map[string]bool, len(tasks))
+	var arns []string
+	for _, t := range tasks {
+		arn := aws.StringValue(t.ContainerInstanceArn)
+		if arn == "" || seen[arn] {
+			continue
+		}
+		seen[arn] = true
+		arns = append(arns, arn)
+	}
+	return arns
+}
+
+// runningTaskCount counts the tasks whose last known status is RUNNING.
+func runningTaskCount(tasks []*ecs.Task) int {
+	running := 0
+	for _, t := range tasks {
+		if aws.StringValue(t.LastStatus) == ecs.DesiredStatusRunning {
+			running++
+		}
+	}
+	return running
+}
+
+func (w *Watcher) flushUpdates(deployments []*types.Deployment) {
+	for _, d := range deployments {
+		w.backend.UpdateDeployment(d)
+	}
+}
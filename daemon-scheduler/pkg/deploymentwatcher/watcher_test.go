@@ -0,0 +1,175 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package deploymentwatcher
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/blox/blox/daemon-scheduler/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockBackend struct {
+	mu sync.Mutex
+
+	updates []*types.Deployment
+
+	tasks        []*ecs.Task
+	taskFailures []*ecs.Failure
+	instances    []*ecs.ContainerInstance
+
+	describeTasksCalls              int
+	describeContainerInstancesCalls int
+}
+
+func (m *mockBackend) DescribeTasks(cluster string, taskArns []string) ([]*ecs.Task, []*ecs.Failure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.describeTasksCalls++
+	return m.tasks, m.taskFailures, nil
+}
+
+func (m *mockBackend) DescribeContainerInstances(cluster string, instanceArns []string) ([]*ecs.ContainerInstance, []*ecs.Failure, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.describeContainerInstancesCalls++
+	return m.instances, nil, nil
+}
+
+func (m *mockBackend) UpdateDeployment(d *types.Deployment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates = append(m.updates, d)
+	return nil
+}
+
+func (m *mockBackend) updateCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.updates)
+}
+
+func (m *mockBackend) describeTasksCallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.describeTasksCalls
+}
+
+func (m *mockBackend) describeContainerInstancesCallCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.describeContainerInstancesCalls
+}
+
+func TestWatcherBurstOfDeploymentsDoesNotExceedQPS(t *testing.T) {
+	backend := &mockBackend{}
+	qps := 20.0
+	w := NewDeploymentsWatcher(backend, qps, 10*time.Millisecond)
+	w.pollInterval = 5 * time.Millisecond
+
+	for i := 0; i < 100; i++ {
+		d, err := types.NewDeployment("arn:aws:ecs:us-east-1:123456789123:task-definition/test:1", "token")
+		assert.Nil(t, err, "Unexpected error creating deployment")
+		w.Watch(d)
+	}
+
+	// Sample the limiter's call rate over a short window; it should never
+	// let through more than roughly qps calls per second.
+	start := time.Now()
+	time.Sleep(200 * time.Millisecond)
+	calls := backend.updateCount()
+	elapsed := time.Since(start).Seconds()
+
+	assert.True(t, float64(calls) <= qps*elapsed+qps, "observed %d reconciliations over %.2fs, which exceeds the %v QPS budget", calls, elapsed, qps)
+}
+
+func TestWatcherRemoveStopsGoroutineWithinOnePollCycle(t *testing.T) {
+	backend := &mockBackend{}
+	batchDur := 5 * time.Millisecond
+	w := NewDeploymentsWatcher(backend, 1000, batchDur)
+	w.pollInterval = 5 * time.Millisecond
+
+	d, err := types.NewDeployment("arn:aws:ecs:us-east-1:123456789123:task-definition/test:1", "token")
+	assert.Nil(t, err, "Unexpected error creating deployment")
+	// No task is ever running, so the deployment stays InProgress and keeps
+	// being reconciled until Remove stops it.
+	d.DesiredTaskCount = 1
+	w.Watch(d)
+
+	time.Sleep(20 * time.Millisecond)
+	w.Remove(d.ID)
+
+	// At most one reconciliation may already be in flight when Remove is
+	// called; give it time to drain through the batcher, then confirm the
+	// count stays flat afterwards.
+	assert.Eventually(t, func() bool {
+		before := backend.updateCount()
+		time.Sleep(10 * batchDur)
+		return backend.updateCount() == before
+	}, time.Second, 10*batchDur, "reconciliation count should stabilize shortly after Remove")
+}
+
+func TestWatcherReconcileDescribesEcsStateAndCompletesDeployment(t *testing.T) {
+	taskArn := "arn:aws:ecs:us-east-1:123456789123:task/cluster/task"
+	instanceArn := "arn:aws:ecs:us-east-1:123456789123:container-instance/cluster/instance"
+
+	backend := &mockBackend{
+		tasks: []*ecs.Task{
+			{
+				ContainerInstanceArn: aws.String(instanceArn),
+				LastStatus:           aws.String(ecs.DesiredStatusRunning),
+			},
+		},
+	}
+	w := NewDeploymentsWatcher(backend, 1000, time.Millisecond)
+	w.pollInterval = 5 * time.Millisecond
+
+	d, err := types.NewDeployment("arn:aws:ecs:us-east-1:123456789123:task-definition/test:1", "token")
+	assert.Nil(t, err, "Unexpected error creating deployment")
+	d.Cluster = "cluster"
+	d.TaskArns = []string{taskArn}
+	d.DesiredTaskCount = 1
+	w.Watch(d)
+
+	assert.Eventually(t, func() bool {
+		return backend.describeTasksCallCount() > 0 && backend.describeContainerInstancesCallCount() > 0
+	}, time.Second, 5*time.Millisecond, "reconcile should describe both tasks and container instances")
+
+	assert.Eventually(t, func() bool {
+		return d.CurrentStatus() == types.DeploymentCompleted
+	}, time.Second, 5*time.Millisecond, "deployment should complete once the desired tasks are running with no failures")
+}
+
+func TestWatcherSetEnabledPausesReconciliation(t *testing.T) {
+	backend := &mockBackend{}
+	w := NewDeploymentsWatcher(backend, 1000, time.Millisecond)
+	w.pollInterval = 5 * time.Millisecond
+	w.SetEnabled(false)
+
+	d, err := types.NewDeployment("arn:aws:ecs:us-east-1:123456789123:task-definition/test:1", "token")
+	assert.Nil(t, err, "Unexpected error creating deployment")
+	w.Watch(d)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, backend.updateCount(), "no reconciliations should occur while the watcher is disabled")
+
+	w.SetEnabled(true)
+	assert.Eventually(t, func() bool {
+		return backend.updateCount() > 0
+	}, time.Second, 5*time.Millisecond, "reconciliations should resume once the watcher is re-enabled")
+}
@@ -0,0 +1,88 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import "github.com/pkg/errors"
+
+// LaunchType selects the ECS capacity a deployment's tasks run on.
+type LaunchType string
+
+const (
+	// LaunchTypeEC2 runs tasks on container instances registered to the
+	// cluster.
+	LaunchTypeEC2 LaunchType = "EC2"
+
+	// LaunchTypeFargate runs tasks on AWS-managed Fargate capacity.
+	LaunchTypeFargate LaunchType = "FARGATE"
+)
+
+// CapacityProviderStrategyItem is one entry in a deployment's capacity
+// provider strategy, mirroring the ECS API's CapacityProviderStrategyItem.
+type CapacityProviderStrategyItem struct {
+	// Provider is the capacity provider name, e.g. "FARGATE" or
+	// "FARGATE_SPOT".
+	Provider string
+
+	// Weight determines the relative proportion of tasks placed using
+	// this provider.
+	Weight int
+
+	// Base is the minimum number of tasks placed using this provider
+	// before weights are applied.
+	Base int
+}
+
+// validateCapacityProviderConfig enforces the same constraints ECS itself
+// applies: launchType and capacityProviderStrategy are mutually exclusive,
+// and a non-empty strategy's weights must not sum to zero.
+func validateCapacityProviderConfig(launchType LaunchType, capacityProviderStrategy []CapacityProviderStrategyItem) error {
+	if launchType != "" && len(capacityProviderStrategy) > 0 {
+		return errors.New("LaunchType and CapacityProviderStrategy are mutually exclusive")
+	}
+
+	if len(capacityProviderStrategy) > 0 {
+		totalWeight := 0
+		for _, item := range capacityProviderStrategy {
+			totalWeight += item.Weight
+		}
+		if totalWeight == 0 {
+			return errors.New("CapacityProviderStrategy weights must not sum to zero")
+		}
+	}
+
+	return nil
+}
+
+// NewDeploymentWithLaunchConfig initializes a deployment as
+// NewDeploymentWithStrategy does, additionally recording the ECS launch
+// type or capacity provider strategy to place its tasks with. Pass an
+// empty launchType and a nil/empty capacityProviderStrategy to leave
+// placement up to the cluster's default capacity provider strategy.
+func NewDeploymentWithLaunchConfig(taskDefinition string, token string, strategy DeploymentStrategy, batchSize int,
+	launchType LaunchType, capacityProviderStrategy []CapacityProviderStrategyItem) (*Deployment, error) {
+
+	if err := validateCapacityProviderConfig(launchType, capacityProviderStrategy); err != nil {
+		return nil, err
+	}
+
+	deployment, err := NewDeploymentWithStrategy(taskDefinition, token, strategy, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment.LaunchType = launchType
+	deployment.CapacityProviderStrategy = capacityProviderStrategy
+
+	return deployment, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDeploymentWithLaunchConfigLaunchTypeOnly(t *testing.T) {
+	d, err := NewDeploymentWithLaunchConfig(taskDefinition, "token", DeploymentStrategyRollingUpdate, 1, LaunchTypeFargate, nil)
+	assert.Nil(t, err, "Unexpected error when creating a deployment with a launch type")
+	assert.Exactly(t, LaunchTypeFargate, d.LaunchType, "Deployment launch type does not match expected")
+	assert.Empty(t, d.CapacityProviderStrategy, "Deployment should not have a capacity provider strategy")
+}
+
+func TestNewDeploymentWithLaunchConfigCapacityProviderStrategyOnly(t *testing.T) {
+	strategy := []CapacityProviderStrategyItem{
+		{Provider: "FARGATE", Weight: 1, Base: 1},
+		{Provider: "FARGATE_SPOT", Weight: 3},
+	}
+
+	d, err := NewDeploymentWithLaunchConfig(taskDefinition, "token", DeploymentStrategyRollingUpdate, 1, "", strategy)
+	assert.Nil(t, err, "Unexpected error when creating a deployment with a capacity provider strategy")
+	assert.Empty(t, d.LaunchType, "Deployment should not have a launch type")
+	assert.Exactly(t, strategy, d.CapacityProviderStrategy, "Deployment capacity provider strategy does not match expected")
+}
+
+func TestNewDeploymentWithLaunchConfigMutuallyExclusive(t *testing.T) {
+	strategy := []CapacityProviderStrategyItem{{Provider: "FARGATE", Weight: 1}}
+
+	_, err := NewDeploymentWithLaunchConfig(taskDefinition, "token", DeploymentStrategyRollingUpdate, 1, LaunchTypeFargate, strategy)
+	assert.Error(t, err, "Expected an error when both LaunchType and CapacityProviderStrategy are set")
+}
+
+func TestNewDeploymentWithLaunchConfigZeroWeightSum(t *testing.T) {
+	strategy := []CapacityProviderStrategyItem{
+		{Provider: "FARGATE", Weight: 0},
+		{Provider: "FARGATE_SPOT", Weight: 0},
+	}
+
+	_, err := NewDeploymentWithLaunchConfig(taskDefinition, "token", DeploymentStrategyRollingUpdate, 1, "", strategy)
+	assert.Error(t, err, "Expected an error when capacity provider weights sum to zero")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressRecordsPlacementsByProvider() {
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, map[string]int{
+		"FARGATE":      3,
+		"FARGATE_SPOT": 2,
+	})
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+	assert.Exactly(suite.T(), 3, suite.deployment.PlacementsByProvider["FARGATE"], "FARGATE placement count does not match expected")
+	assert.Exactly(suite.T(), 2, suite.deployment.PlacementsByProvider["FARGATE_SPOT"], "FARGATE_SPOT placement count does not match expected")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressAccumulatesPlacementsAcrossWaves() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, map[string]int{"FARGATE": 2})
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, map[string]int{"FARGATE": 3})
+
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+	assert.Exactly(suite.T(), 5, suite.deployment.PlacementsByProvider["FARGATE"], "PlacementsByProvider should accumulate across waves")
+}
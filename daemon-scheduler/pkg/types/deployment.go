@@ -0,0 +1,522 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+)
+
+// DeploymentStatus describes where a deployment is in its lifecycle.
+type DeploymentStatus string
+
+const (
+	// DeploymentPending indicates that the deployment has been created but
+	// has not yet started placing tasks.
+	DeploymentPending DeploymentStatus = "pending"
+
+	// DeploymentInProgress indicates that the deployment is actively
+	// placing or draining tasks.
+	DeploymentInProgress DeploymentStatus = "inProgress"
+
+	// DeploymentCompleted indicates that the deployment has finished,
+	// successfully or otherwise.
+	DeploymentCompleted DeploymentStatus = "completed"
+)
+
+// DeploymentHealth describes whether a deployment is progressing cleanly.
+type DeploymentHealth string
+
+const (
+	// DeploymentHealthy indicates that the deployment has encountered no failures.
+	DeploymentHealthy DeploymentHealth = "healthy"
+
+	// DeploymentUnhealthy indicates that the deployment has encountered at
+	// least one failure.
+	DeploymentUnhealthy DeploymentHealth = "unhealthy"
+)
+
+// DeploymentConditionType is the type of a condition reported on a
+// deployment, modeled on Kubernetes deployment conditions.
+type DeploymentConditionType string
+
+const (
+	// DeploymentProgressing indicates whether the deployment is actively
+	// making progress towards its desired state.
+	DeploymentProgressing DeploymentConditionType = "Progressing"
+
+	// DeploymentAvailable indicates whether the deployment has the
+	// minimum number of tasks available.
+	DeploymentAvailable DeploymentConditionType = "Available"
+
+	// DeploymentReplicaFailure indicates a problem placing or maintaining
+	// tasks, such as failures that have exceeded their retry budget.
+	DeploymentReplicaFailure DeploymentConditionType = "ReplicaFailure"
+)
+
+// ConditionStatus is the observed status of a DeploymentCondition.
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+)
+
+// Condition reasons surfaced on deployment conditions.
+const (
+	ReasonNewTasksAvailable        = "NewTasksAvailable"
+	ReasonPlacementFailed          = "PlacementFailed"
+	ReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	ReasonDeploymentComplete       = "DeploymentComplete"
+)
+
+// DeploymentCondition is a point-in-time observation of one aspect of a
+// deployment's health.
+type DeploymentCondition struct {
+	Type               DeploymentConditionType
+	Status             ConditionStatus
+	Reason             string
+	Message            string
+	LastTransitionTime time.Time
+}
+
+// DeploymentStrategy describes how a deployment replaces the tasks running
+// an old task definition with tasks running a new one.
+type DeploymentStrategy string
+
+const (
+	// DeploymentStrategyRollingUpdate brings up new tasks in batches while
+	// draining old ones, so that some capacity remains available for the
+	// whole rollout. This is the default strategy.
+	DeploymentStrategyRollingUpdate DeploymentStrategy = "RollingUpdate"
+
+	// DeploymentStrategyRecreate stops all old tasks before any new tasks
+	// are started, trading availability for the guarantee that old and new
+	// tasks never run side by side.
+	DeploymentStrategyRecreate DeploymentStrategy = "Recreate"
+
+	// defaultBatchSize is used when a caller does not specify one for the
+	// RollingUpdate strategy.
+	defaultBatchSize = 1
+)
+
+// DeploymentRevision archives the state of a deployment that has completed,
+// so that a later deployment can roll back to it.
+type DeploymentRevision struct {
+	Revision         int
+	TaskDefinition   string
+	DesiredTaskCount int
+	Health           DeploymentHealth
+}
+
+// Deployment represents a deployment of a task definition across the tasks
+// started and maintained by an environment.
+//
+// A Deployment is typically mutated by exactly one goroutine at a time (the
+// caller driving it through NewDeployment/UpdateDeploymentToInProgress/
+// UpdateDeploymentToCompleted, or the deployment watcher once the deployment
+// is handed to it). mu guards against the case where that assumption
+// doesn't hold, e.g. an API handler applying an ECS event concurrently with
+// the watcher's own reconciliation: exported methods that read or write
+// fields below take mu, and CurrentStatus should be used instead of reading
+// the Status field directly from any goroutine other than the one driving
+// the deployment.
+type Deployment struct {
+	mu sync.Mutex
+
+	ID               string
+	Status           DeploymentStatus
+	StartTime        time.Time
+	EndTime          time.Time
+	TaskDefinition   string
+	DesiredTaskCount int
+	FailedInstances  []*ecs.Failure
+	Token            string
+
+	// Cluster is the ECS cluster this deployment places tasks in. Required
+	// for the deployment watcher to reconcile the deployment against ECS
+	// state.
+	Cluster string
+
+	// TaskArns holds the ARNs of the tasks this deployment has placed so
+	// far, so the watcher can describe their current status.
+	TaskArns []string
+
+	// Conditions holds the latest observed status of each aspect of the
+	// deployment's health. Use GetCondition to read a specific condition
+	// and Health for the coarse healthy/unhealthy summary.
+	Conditions []DeploymentCondition
+
+	// ProgressDeadline bounds how long after StartTime the deployment may
+	// still be InProgress before Progressing is flipped to False with reason
+	// ProgressDeadlineExceeded. Zero means no deadline is enforced.
+	ProgressDeadline time.Duration
+
+	// Strategy controls how old tasks are replaced with new ones.
+	// Defaults to DeploymentStrategyRollingUpdate.
+	Strategy DeploymentStrategy
+
+	// BatchSize is the number of tasks replaced per wave under the
+	// RollingUpdate strategy. It cannot exceed DesiredTaskCount.
+	BatchSize int
+
+	// TasksBeingReplaced is the number of tasks currently being replaced in
+	// the in-progress wave.
+	TasksBeingReplaced int
+
+	// Revisions holds the history of completed rollouts for this
+	// deployment's lineage, ordered oldest first and keyed by a
+	// monotonically increasing revision number.
+	Revisions []DeploymentRevision
+
+	// SourceRevision is the revision number this deployment was rolled back
+	// to, if it was created by NewRollbackDeployment.
+	SourceRevision int
+
+	// RetryPolicy governs backoff for instances that fail placement. A nil
+	// RetryPolicy disables retry bookkeeping: failures are still recorded
+	// on FailedInstances, but never tracked or promoted to
+	// PermanentFailures.
+	RetryPolicy *RetryPolicy
+
+	// RetryableFailures holds the current backoff state of instances from
+	// the latest wave that have not yet exceeded RetryPolicy.MaxAttempts.
+	RetryableFailures []*RetryableFailure
+
+	// PermanentFailures holds instances that have exceeded
+	// RetryPolicy.MaxAttempts and will no longer be retried.
+	PermanentFailures []*ecs.Failure
+
+	// LaunchType is the ECS launch type tasks are placed with. Mutually
+	// exclusive with CapacityProviderStrategy.
+	LaunchType LaunchType
+
+	// CapacityProviderStrategy is the capacity provider strategy tasks are
+	// placed with. Mutually exclusive with LaunchType.
+	CapacityProviderStrategy []CapacityProviderStrategyItem
+
+	// PlacementsByProvider counts, across all waves, how many tasks have
+	// landed on each capacity provider (or launch type) so operators can
+	// see the Spot vs on-demand split.
+	PlacementsByProvider map[string]int
+}
+
+// NewDeployment initializes a deployment with the default RollingUpdate
+// strategy. Use NewDeploymentWithStrategy to select a different strategy or
+// a non-default batch size.
+func NewDeployment(taskDefinition string, token string) (*Deployment, error) {
+	return NewDeploymentWithStrategy(taskDefinition, token, DeploymentStrategyRollingUpdate, 0)
+}
+
+// NewDeploymentWithStrategy initializes a deployment using the given
+// strategy. A batchSize of 0 selects the default batch size for
+// RollingUpdate; it is ignored for Recreate, which always replaces every
+// task in a single wave.
+func NewDeploymentWithStrategy(taskDefinition string, token string, strategy DeploymentStrategy, batchSize int) (*Deployment, error) {
+	if taskDefinition == "" {
+		return nil, errors.New("Task definition should not be empty to create a deployment")
+	}
+
+	if err := validateDeploymentStrategy(strategy); err != nil {
+		return nil, err
+	}
+
+	if batchSize < 0 {
+		return nil, errors.New("Batch size cannot be negative")
+	}
+
+	if strategy == DeploymentStrategyRollingUpdate && batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &Deployment{
+		ID:             uuid.NewRandom().String(),
+		Status:         DeploymentPending,
+		StartTime:      time.Now(),
+		TaskDefinition: taskDefinition,
+		Token:          token,
+		Strategy:       strategy,
+		BatchSize:      batchSize,
+	}, nil
+}
+
+// GetCondition returns the condition of the given type, or nil if the
+// deployment has not yet reported one. Callers must not assume Conditions
+// is populated or ordered.
+func (deployment *Deployment) GetCondition(cType DeploymentConditionType) *DeploymentCondition {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+	return deployment.getCondition(cType)
+}
+
+// getCondition is GetCondition's unlocked implementation, for use by methods
+// that already hold mu.
+func (deployment *Deployment) getCondition(cType DeploymentConditionType) *DeploymentCondition {
+	for i := range deployment.Conditions {
+		if deployment.Conditions[i].Type == cType {
+			return &deployment.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// setCondition inserts or updates the condition of the given type,
+// refreshing LastTransitionTime only when the status actually changes. It
+// must be called with mu held.
+func (deployment *Deployment) setCondition(cType DeploymentConditionType, status ConditionStatus, reason, message string) {
+	now := time.Now()
+	if existing := deployment.getCondition(cType); existing != nil {
+		if existing.Status != status {
+			existing.LastTransitionTime = now
+		}
+		existing.Status = status
+		existing.Reason = reason
+		existing.Message = message
+		return
+	}
+
+	deployment.Conditions = append(deployment.Conditions, DeploymentCondition{
+		Type:               cType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: now,
+	})
+}
+
+// Health derives a coarse DeploymentHealthy/DeploymentUnhealthy summary from
+// the deployment's conditions, for callers that do not need condition-level
+// detail. Note this is a method, not the old DeploymentHealthy/Unhealthy
+// field it replaces, so it is a source-breaking rename rather than a purely
+// additive backward-compatible getter; nothing else in this tree still
+// referenced the field.
+func (deployment *Deployment) Health() DeploymentHealth {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+	return deployment.health()
+}
+
+// health is Health's unlocked implementation, for use by methods that
+// already hold mu.
+func (deployment *Deployment) health() DeploymentHealth {
+	if c := deployment.getCondition(DeploymentReplicaFailure); c != nil && c.Status == ConditionTrue {
+		return DeploymentUnhealthy
+	}
+	if c := deployment.getCondition(DeploymentAvailable); c != nil && c.Status == ConditionFalse {
+		return DeploymentUnhealthy
+	}
+	return DeploymentHealthy
+}
+
+// CurrentStatus returns the deployment's status. Reading the Status field
+// directly is safe from the goroutine driving the deployment, but any other
+// goroutine (for example the deployment watcher observing a deployment
+// mutated elsewhere) must use CurrentStatus instead.
+func (deployment *Deployment) CurrentStatus() DeploymentStatus {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+	return deployment.Status
+}
+
+// ReconciliationTarget returns a snapshot of the cluster, task ARNs, and
+// desired task count the deployment should currently be reconciled against.
+// Like CurrentStatus, this must be used instead of reading the Cluster,
+// TaskArns, and DesiredTaskCount fields directly from any goroutine other
+// than the one driving the deployment.
+func (deployment *Deployment) ReconciliationTarget() (cluster string, taskArns []string, desiredTaskCount int) {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+	return deployment.Cluster, deployment.TaskArns, deployment.DesiredTaskCount
+}
+
+// CheckProgressDeadline flips the Progressing condition to False with
+// reason ProgressDeadlineExceeded if the deployment is still InProgress and
+// more than ProgressDeadline has passed since StartTime. It is a no-op when
+// ProgressDeadline is zero (unset) or the deployment is not InProgress.
+func (deployment *Deployment) CheckProgressDeadline(now time.Time) {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+
+	if deployment.ProgressDeadline == 0 || deployment.Status != DeploymentInProgress {
+		return
+	}
+	if now.Sub(deployment.StartTime) > deployment.ProgressDeadline {
+		deployment.setCondition(DeploymentProgressing, ConditionFalse, ReasonProgressDeadlineExceeded,
+			"Deployment did not complete within its progress deadline")
+	}
+}
+
+func validateDeploymentStrategy(strategy DeploymentStrategy) error {
+	switch strategy {
+	case DeploymentStrategyRollingUpdate, DeploymentStrategyRecreate:
+		return nil
+	default:
+		return errors.Errorf("Invalid deployment strategy '%s'", strategy)
+	}
+}
+
+// UpdateDeploymentToInProgress transitions the deployment to the InProgress
+// status, recording the desired task count, any failures encountered so
+// far, the size of the current replacement wave, and how many tasks landed
+// on each capacity provider in this wave. placementsByProvider may be nil
+// if the caller has no per-provider accounting to report.
+func (deployment *Deployment) UpdateDeploymentToInProgress(desiredTaskCount int, failures []*ecs.Failure, placementsByProvider map[string]int) error {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+
+	if deployment.Status == DeploymentCompleted {
+		return errors.New("Cannot update an already completed deployment to in progress")
+	}
+
+	batchSize := deployment.BatchSize
+	if deployment.Strategy == DeploymentStrategyRecreate {
+		batchSize = desiredTaskCount
+	}
+	if batchSize > desiredTaskCount {
+		return errors.Errorf("Batch size %d cannot exceed desired task count %d", batchSize, desiredTaskCount)
+	}
+
+	deployment.Status = DeploymentInProgress
+	deployment.DesiredTaskCount = desiredTaskCount
+	deployment.FailedInstances = failures
+	deployment.TasksBeingReplaced = batchSize
+
+	if deployment.RetryPolicy != nil {
+		deployment.trackRetries(failures)
+	}
+
+	if len(placementsByProvider) > 0 {
+		if deployment.PlacementsByProvider == nil {
+			deployment.PlacementsByProvider = make(map[string]int, len(placementsByProvider))
+		}
+		for provider, count := range placementsByProvider {
+			deployment.PlacementsByProvider[provider] += count
+		}
+	}
+
+	deployment.setCondition(DeploymentProgressing, ConditionTrue, ReasonNewTasksAvailable,
+		"Deployment is placing new tasks")
+
+	if len(failures) > 0 {
+		deployment.setCondition(DeploymentReplicaFailure, ConditionTrue, ReasonPlacementFailed,
+			"One or more tasks failed to be placed")
+	} else {
+		deployment.setCondition(DeploymentReplicaFailure, ConditionFalse, "", "")
+	}
+
+	return nil
+}
+
+// UpdateDeploymentToCompleted transitions the deployment to the Completed
+// status, recording any failures encountered during the final wave and
+// archiving the outcome as a new revision.
+func (deployment *Deployment) UpdateDeploymentToCompleted(failures []*ecs.Failure) error {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+
+	deployment.Status = DeploymentCompleted
+	deployment.EndTime = time.Now()
+	deployment.FailedInstances = failures
+	deployment.TasksBeingReplaced = 0
+
+	deployment.setCondition(DeploymentProgressing, ConditionFalse, ReasonDeploymentComplete,
+		"Deployment has completed")
+
+	if len(failures) > 0 {
+		deployment.setCondition(DeploymentReplicaFailure, ConditionTrue, ReasonPlacementFailed,
+			"One or more tasks failed to be placed")
+		deployment.setCondition(DeploymentAvailable, ConditionFalse, ReasonPlacementFailed,
+			"Deployment completed with failed tasks")
+	} else {
+		deployment.setCondition(DeploymentReplicaFailure, ConditionFalse, "", "")
+		deployment.setCondition(DeploymentAvailable, ConditionTrue, ReasonNewTasksAvailable,
+			"All desired tasks are available")
+	}
+
+	deployment.Revisions = append(deployment.Revisions, DeploymentRevision{
+		Revision:         len(deployment.Revisions) + 1,
+		TaskDefinition:   deployment.TaskDefinition,
+		DesiredTaskCount: deployment.DesiredTaskCount,
+		Health:           deployment.health(),
+	})
+
+	return nil
+}
+
+// revisionByNumber returns the revision with the given number, or an error
+// if it is not present.
+func revisionByNumber(revisions []DeploymentRevision, revision int) (DeploymentRevision, error) {
+	for _, r := range revisions {
+		if r.Revision == revision {
+			return r, nil
+		}
+	}
+	return DeploymentRevision{}, errors.Errorf("Revision %d not found", revision)
+}
+
+// NewRollbackDeployment creates a new deployment that targets the task
+// definition recorded in a prior revision of prev. The returned deployment
+// inherits prev's strategy and revision history, and is stamped with
+// SourceRevision so callers can tell it apart from a forward rollout.
+func NewRollbackDeployment(prev *Deployment, targetRevision int, token string) (*Deployment, error) {
+	if prev == nil {
+		return nil, errors.New("Cannot roll back without a previous deployment")
+	}
+
+	prev.mu.Lock()
+	revisions, strategy, batchSize := prev.Revisions, prev.Strategy, prev.BatchSize
+	prev.mu.Unlock()
+
+	target, err := revisionByNumber(revisions, targetRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback, err := NewDeploymentWithStrategy(target.TaskDefinition, token, strategy, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	rollback.Revisions = revisions
+	rollback.SourceRevision = targetRevision
+
+	return rollback, nil
+}
+
+// Rollback reverts the deployment in place to the task definition recorded
+// under targetRevision. It only makes sense before the deployment has been
+// started, since ECS state is reconciled against TaskDefinition.
+func (deployment *Deployment) Rollback(targetRevision int) error {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+
+	if deployment.Status != DeploymentPending {
+		return errors.New("Cannot roll back a deployment that has already started")
+	}
+
+	target, err := revisionByNumber(deployment.Revisions, targetRevision)
+	if err != nil {
+		return err
+	}
+
+	deployment.TaskDefinition = target.TaskDefinition
+	deployment.SourceRevision = targetRevision
+
+	return nil
+}
@@ -15,6 +15,7 @@ package types
 
 import (
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecs"
@@ -26,6 +27,7 @@ import (
 const (
 	taskArn          = "arn:aws:ecs:us-east-1:12345678912:task/c024d145-093b-499a-9b14-5baf273f5835"
 	instanceArn      = "arn:aws:us-east-1:123456789123:container-instance/4b6d45ea-a4b4-4269-9d04-3af6ddfdc597"
+	taskDefinition   = "arn:aws:ecs:us-east-1:12345678912:task-definition/test:1"
 	desiredTaskCount = 5
 )
 
@@ -64,7 +66,7 @@ func (suite *DeploymentTestSuite) TestNewDeployment() {
 	assert.NotNil(suite.T(), d, "Deployment should not be nil")
 	assert.NotEmpty(suite.T(), d.ID, "Deployment ID should not be empty")
 	assert.Exactly(suite.T(), DeploymentPending, d.Status, "Deployment status should be pending")
-	assert.Exactly(suite.T(), DeploymentHealthy, d.Health, "Deployment should be healthy")
+	assert.Exactly(suite.T(), DeploymentHealthy, d.Health(), "Deployment should be healthy")
 	assert.NotNil(suite.T(), d.StartTime, "Deployment startTime should not be empty")
 	assert.Empty(suite.T(), d.EndTime, "Deployment endtime should be empty")
 	assert.Exactly(suite.T(), taskDefinition, d.TaskDefinition, "Deployment taskDefintion does not match expected")
@@ -73,17 +75,17 @@ func (suite *DeploymentTestSuite) TestNewDeployment() {
 func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressDeploymentCompleted() {
 	suite.deployment.Status = DeploymentCompleted
 
-	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures)
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
 	assert.Error(suite.T(), err, "Expected an error when deployment is complete")
 }
 
 func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressUnhealthy() {
-	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures)
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
 	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
 	assert.NotNil(suite.T(), suite.deployment, "Deployment should not be nil")
 	assert.NotEmpty(suite.T(), suite.deployment.ID, "Deployment ID should not be empty")
 	assert.Exactly(suite.T(), DeploymentInProgress, suite.deployment.Status, "Deployment status should be inprogress")
-	assert.Exactly(suite.T(), DeploymentUnhealthy, suite.deployment.Health, "Deployment should be unhealthy")
+	assert.Exactly(suite.T(), DeploymentUnhealthy, suite.deployment.Health(), "Deployment should be unhealthy")
 	assert.Exactly(suite.T(), desiredTaskCount, suite.deployment.DesiredTaskCount, "Deployment desired task count should match expected")
 	assert.NotNil(suite.T(), suite.deployment.StartTime, "Deployment startTime should not be empty")
 	assert.Empty(suite.T(), suite.deployment.EndTime, "Deployment endtime should be empty")
@@ -92,12 +94,12 @@ func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressUnhealthy() {
 }
 
 func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressHealthy() {
-	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{})
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
 	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
 	assert.NotNil(suite.T(), suite.deployment, "Deployment should not be nil")
 	assert.NotEmpty(suite.T(), suite.deployment.ID, "Deployment ID should not be empty")
 	assert.Exactly(suite.T(), DeploymentInProgress, suite.deployment.Status, "Deployment status should be inprogress")
-	assert.Exactly(suite.T(), DeploymentHealthy, suite.deployment.Health, "Deployment should be healthy")
+	assert.Exactly(suite.T(), DeploymentHealthy, suite.deployment.Health(), "Deployment should be healthy")
 	assert.Exactly(suite.T(), desiredTaskCount, suite.deployment.DesiredTaskCount, "Deployment desired task count should match expected")
 	assert.NotNil(suite.T(), suite.deployment.StartTime, "Deployment startTime should not be empty")
 	assert.Empty(suite.T(), suite.deployment.EndTime, "Deployment endtime should be empty")
@@ -106,14 +108,14 @@ func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressHealthy() {
 }
 
 func (suite *DeploymentTestSuite) TestUpdateDeploymentToCompletedUnhealthy() {
-	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures)
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
 
 	err := suite.deployment.UpdateDeploymentToCompleted(suite.failures)
 	assert.Nil(suite.T(), err, "Unexpected error when setting deployment to completed")
 	assert.NotNil(suite.T(), suite.deployment, "Deployment should not be nil")
 	assert.NotEmpty(suite.T(), suite.deployment.ID, "Deployment ID should not be empty")
 	assert.Exactly(suite.T(), DeploymentCompleted, suite.deployment.Status, "Deployment status should be completed")
-	assert.Exactly(suite.T(), DeploymentUnhealthy, suite.deployment.Health, "Deployment should not be healthy")
+	assert.Exactly(suite.T(), DeploymentUnhealthy, suite.deployment.Health(), "Deployment should not be healthy")
 	assert.Exactly(suite.T(), desiredTaskCount, suite.deployment.DesiredTaskCount, "Deployment desired task count should match expected")
 	assert.NotNil(suite.T(), suite.deployment.StartTime, "Deployment startTime should not be empty")
 	assert.NotNil(suite.T(), suite.deployment.EndTime, "Deployment endtime should not be empty")
@@ -122,17 +124,183 @@ func (suite *DeploymentTestSuite) TestUpdateDeploymentToCompletedUnhealthy() {
 }
 
 func (suite *DeploymentTestSuite) TestUpdateDeploymentToCompletedHealthy() {
-	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures)
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
 
 	err := suite.deployment.UpdateDeploymentToCompleted(nil)
 	assert.Nil(suite.T(), err, "Unexpected error when setting deployment to completed")
 	assert.NotNil(suite.T(), suite.deployment, "Deployment should not be nil")
 	assert.NotEmpty(suite.T(), suite.deployment.ID, "Deployment ID should not be empty")
 	assert.Exactly(suite.T(), DeploymentCompleted, suite.deployment.Status, "Deployment status should be completed")
-	assert.Exactly(suite.T(), DeploymentHealthy, suite.deployment.Health, "Deployment should be healthy")
+	assert.Exactly(suite.T(), DeploymentHealthy, suite.deployment.Health(), "Deployment should be healthy")
 	assert.Exactly(suite.T(), desiredTaskCount, suite.deployment.DesiredTaskCount, "Deployment desired task count should match expected")
 	assert.NotNil(suite.T(), suite.deployment.StartTime, "Deployment startTime should not be empty")
 	assert.NotNil(suite.T(), suite.deployment.EndTime, "Deployment endtime should not be empty")
 	assert.Exactly(suite.T(), taskDefinition, suite.deployment.TaskDefinition, "Deployment taskDefintion does not match expected")
 	assert.Empty(suite.T(), suite.deployment.FailedInstances, "Deployment failed instances does not match expected")
 }
+
+func (suite *DeploymentTestSuite) TestNewDeploymentDefaultsToRollingUpdate() {
+	d, err := NewDeployment(taskDefinition, suite.token)
+	assert.Nil(suite.T(), err, "Unexpected error when creating a deployment")
+	assert.Exactly(suite.T(), DeploymentStrategyRollingUpdate, d.Strategy, "Deployment should default to the RollingUpdate strategy")
+	assert.Exactly(suite.T(), defaultBatchSize, d.BatchSize, "Deployment should default to a batch size of 1")
+}
+
+func (suite *DeploymentTestSuite) TestNewDeploymentWithStrategyInvalidStrategy() {
+	_, err := NewDeploymentWithStrategy(taskDefinition, suite.token, DeploymentStrategy("BlueGreen"), 1)
+	assert.Error(suite.T(), err, "Expected an error when the deployment strategy is invalid")
+}
+
+func (suite *DeploymentTestSuite) TestNewDeploymentWithStrategyRecreate() {
+	d, err := NewDeploymentWithStrategy(taskDefinition, suite.token, DeploymentStrategyRecreate, 0)
+	assert.Nil(suite.T(), err, "Unexpected error when creating a Recreate deployment")
+	assert.Exactly(suite.T(), DeploymentStrategyRecreate, d.Strategy, "Deployment strategy does not match expected")
+}
+
+func (suite *DeploymentTestSuite) TestNewDeploymentWithStrategyNegativeBatchSize() {
+	_, err := NewDeploymentWithStrategy(taskDefinition, suite.token, DeploymentStrategyRollingUpdate, -1)
+	assert.Error(suite.T(), err, "Expected an error when the batch size is negative")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressBatchSizeExceedsDesiredTaskCount() {
+	suite.deployment.BatchSize = desiredTaskCount + 1
+
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	assert.Error(suite.T(), err, "Expected an error when batch size exceeds desired task count")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressRecordsTasksBeingReplaced() {
+	suite.deployment.BatchSize = 2
+
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+	assert.Exactly(suite.T(), 2, suite.deployment.TasksBeingReplaced, "Deployment should record the batch size as tasks being replaced")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressRecreateReplacesAllTasksInOneWave() {
+	recreate, err := NewDeploymentWithStrategy(taskDefinition, suite.token, DeploymentStrategyRecreate, 0)
+	assert.Nil(suite.T(), err, "Unexpected error when creating a Recreate deployment")
+
+	err = recreate.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+	assert.Exactly(suite.T(), desiredTaskCount, recreate.TasksBeingReplaced, "Recreate should replace every task in a single wave")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToCompletedArchivesRevision() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+
+	err := suite.deployment.UpdateDeploymentToCompleted(nil)
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment to completed")
+	assert.Len(suite.T(), suite.deployment.Revisions, 1, "Deployment should have archived one revision")
+	assert.Exactly(suite.T(), 1, suite.deployment.Revisions[0].Revision, "First revision should be numbered 1")
+	assert.Exactly(suite.T(), taskDefinition, suite.deployment.Revisions[0].TaskDefinition, "Revision task definition does not match expected")
+	assert.Exactly(suite.T(), desiredTaskCount, suite.deployment.Revisions[0].DesiredTaskCount, "Revision desired task count does not match expected")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToCompletedAppendsSuccessiveRevisions() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	suite.deployment.UpdateDeploymentToCompleted(nil)
+
+	suite.deployment.Status = DeploymentPending
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	err := suite.deployment.UpdateDeploymentToCompleted(nil)
+
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment to completed")
+	assert.Len(suite.T(), suite.deployment.Revisions, 2, "Deployment should have archived two revisions")
+	assert.Exactly(suite.T(), 2, suite.deployment.Revisions[1].Revision, "Second revision should be numbered 2")
+}
+
+func (suite *DeploymentTestSuite) TestNewRollbackDeploymentUnknownRevision() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	suite.deployment.UpdateDeploymentToCompleted(nil)
+
+	_, err := NewRollbackDeployment(suite.deployment, 5, suite.token)
+	assert.Error(suite.T(), err, "Expected an error when the target revision does not exist")
+}
+
+func (suite *DeploymentTestSuite) TestNewRollbackDeployment() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	suite.deployment.UpdateDeploymentToCompleted(nil)
+
+	rollback, err := NewRollbackDeployment(suite.deployment, 1, suite.token)
+	assert.Nil(suite.T(), err, "Unexpected error when rolling back a deployment")
+	assert.Exactly(suite.T(), taskDefinition, rollback.TaskDefinition, "Rollback task definition does not match expected")
+	assert.Exactly(suite.T(), 1, rollback.SourceRevision, "Rollback should record its source revision")
+	assert.Exactly(suite.T(), DeploymentPending, rollback.Status, "Rollback deployment should start out pending")
+}
+
+func (suite *DeploymentTestSuite) TestDeploymentRollbackUnknownRevision() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	suite.deployment.UpdateDeploymentToCompleted(nil)
+	suite.deployment.Status = DeploymentPending
+
+	err := suite.deployment.Rollback(5)
+	assert.Error(suite.T(), err, "Expected an error when the target revision does not exist")
+}
+
+func (suite *DeploymentTestSuite) TestDeploymentRollbackAfterStartedFails() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	suite.deployment.UpdateDeploymentToCompleted(nil)
+
+	err := suite.deployment.Rollback(1)
+	assert.Error(suite.T(), err, "Expected an error when rolling back a deployment that has already started")
+}
+
+func (suite *DeploymentTestSuite) TestGetConditionAbsent() {
+	assert.Nil(suite.T(), suite.deployment.GetCondition(DeploymentAvailable), "GetCondition should return nil for a condition that has not been set")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressSetsProgressingCondition() {
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+
+	progressing := suite.deployment.GetCondition(DeploymentProgressing)
+	assert.NotNil(suite.T(), progressing, "Progressing condition should be set")
+	assert.Exactly(suite.T(), ConditionTrue, progressing.Status, "Progressing condition should be True")
+	assert.Exactly(suite.T(), ReasonNewTasksAvailable, progressing.Reason, "Progressing condition reason does not match expected")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressSetsReplicaFailureCondition() {
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+
+	replicaFailure := suite.deployment.GetCondition(DeploymentReplicaFailure)
+	assert.NotNil(suite.T(), replicaFailure, "ReplicaFailure condition should be set")
+	assert.Exactly(suite.T(), ConditionTrue, replicaFailure.Status, "ReplicaFailure condition should be True")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToCompletedSetsAvailableCondition() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+
+	err := suite.deployment.UpdateDeploymentToCompleted(nil)
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment to completed")
+
+	available := suite.deployment.GetCondition(DeploymentAvailable)
+	assert.NotNil(suite.T(), available, "Available condition should be set")
+	assert.Exactly(suite.T(), ConditionTrue, available.Status, "Available condition should be True")
+
+	progressing := suite.deployment.GetCondition(DeploymentProgressing)
+	assert.NotNil(suite.T(), progressing, "Progressing condition should be set")
+	assert.Exactly(suite.T(), ConditionFalse, progressing.Status, "Progressing condition should be False once completed")
+}
+
+func (suite *DeploymentTestSuite) TestCheckProgressDeadlineNotExceeded() {
+	suite.deployment.ProgressDeadline = time.Hour
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+
+	suite.deployment.CheckProgressDeadline(suite.deployment.StartTime.Add(time.Minute))
+
+	progressing := suite.deployment.GetCondition(DeploymentProgressing)
+	assert.Exactly(suite.T(), ConditionTrue, progressing.Status, "Progressing condition should remain True before the deadline")
+}
+
+func (suite *DeploymentTestSuite) TestCheckProgressDeadlineExceeded() {
+	suite.deployment.ProgressDeadline = time.Minute
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, []*ecs.Failure{}, nil)
+
+	suite.deployment.CheckProgressDeadline(suite.deployment.StartTime.Add(time.Hour))
+
+	progressing := suite.deployment.GetCondition(DeploymentProgressing)
+	assert.Exactly(suite.T(), ConditionFalse, progressing.Status, "Progressing condition should be False once the deadline has elapsed")
+	assert.Exactly(suite.T(), ReasonProgressDeadlineExceeded, progressing.Reason, "Progressing condition reason does not match expected")
+}
@@ -0,0 +1,153 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+)
+
+// RetryPolicy controls how a deployment backs off before retrying
+// placement on instances that have previously failed.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Jitter      float64
+}
+
+// RetryableFailure tracks the retry state of a single failed instance
+// across successive UpdateDeploymentToInProgress calls.
+type RetryableFailure struct {
+	Failure     *ecs.Failure
+	Attempt     int
+	NextRetryAt time.Time
+}
+
+// findRetryableFailure returns the tracked retry state for the instance
+// with the given ARN, or nil if it is not currently tracked for retry.
+func (deployment *Deployment) findRetryableFailure(arn string) *RetryableFailure {
+	for _, rf := range deployment.RetryableFailures {
+		if aws.StringValue(rf.Failure.Arn) == arn {
+			return rf
+		}
+	}
+	return nil
+}
+
+// isPermanentFailure reports whether the instance with the given ARN has
+// already exceeded RetryPolicy.MaxAttempts and been recorded in
+// PermanentFailures.
+func (deployment *Deployment) isPermanentFailure(arn string) bool {
+	for _, f := range deployment.PermanentFailures {
+		if aws.StringValue(f.Arn) == arn {
+			return true
+		}
+	}
+	return false
+}
+
+// trackRetries folds the failures from the latest wave into the
+// deployment's retry bookkeeping. An instance already in PermanentFailures
+// is left alone: it has exhausted its retries and ECS reporting it as
+// failed again does not re-admit it. An instance whose backoff has not yet
+// elapsed keeps its current attempt count and NextRetryAt unchanged, so a
+// persistently-failing instance actually waits out BaseBackoff/MaxBackoff
+// instead of burning through MaxAttempts on every poll. Only once its
+// backoff has elapsed does the attempt count advance, at which point an
+// instance that has exhausted RetryPolicy.MaxAttempts moves to
+// PermanentFailures.
+func (deployment *Deployment) trackRetries(failures []*ecs.Failure) {
+	policy := deployment.RetryPolicy
+	now := time.Now()
+
+	retryable := make([]*RetryableFailure, 0, len(failures))
+	for _, f := range failures {
+		arn := aws.StringValue(f.Arn)
+		if deployment.isPermanentFailure(arn) {
+			continue
+		}
+
+		existing := deployment.findRetryableFailure(arn)
+		if existing != nil && now.Before(existing.NextRetryAt) {
+			retryable = append(retryable, existing)
+			continue
+		}
+
+		attempt := 1
+		if existing != nil {
+			attempt = existing.Attempt + 1
+		}
+
+		if attempt > policy.MaxAttempts {
+			deployment.PermanentFailures = append(deployment.PermanentFailures, f)
+			deployment.setCondition(DeploymentReplicaFailure, ConditionTrue, ReasonPlacementFailed,
+				"One or more instances exceeded their maximum retry attempts")
+			continue
+		}
+
+		retryable = append(retryable, &RetryableFailure{
+			Failure:     f,
+			Attempt:     attempt,
+			NextRetryAt: now.Add(backoffWithJitter(policy, attempt)),
+		})
+	}
+
+	deployment.RetryableFailures = retryable
+}
+
+// backoffWithJitter computes min(MaxBackoff, BaseBackoff*2^(attempt-1)),
+// then perturbs it by a uniform random offset in [-Jitter, +Jitter] times
+// the unperturbed backoff. A zero MaxBackoff leaves the backoff uncapped,
+// and a jittered result below zero is clamped to zero.
+func backoffWithJitter(policy *RetryPolicy, attempt int) time.Duration {
+	backoff := time.Duration(float64(policy.BaseBackoff) * math.Pow(2, float64(attempt-1)))
+	if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+		backoff = policy.MaxBackoff
+	}
+
+	if policy.Jitter <= 0 {
+		return backoff
+	}
+
+	offset := (rand.Float64()*2 - 1) * policy.Jitter * float64(backoff)
+	jittered := time.Duration(float64(backoff) + offset)
+	if jittered < 0 {
+		jittered = 0
+	}
+	return jittered
+}
+
+// DueForRetry returns the subset of tracked failures whose backoff has
+// elapsed as of now and whose attempt count is still under MaxAttempts.
+func (deployment *Deployment) DueForRetry(now time.Time) []*ecs.Failure {
+	deployment.mu.Lock()
+	defer deployment.mu.Unlock()
+
+	if deployment.RetryPolicy == nil {
+		return nil
+	}
+
+	var due []*ecs.Failure
+	for _, rf := range deployment.RetryableFailures {
+		if rf.Attempt < deployment.RetryPolicy.MaxAttempts && !now.Before(rf.NextRetryAt) {
+			due = append(due, rf.Failure)
+		}
+	}
+	return due
+}
@@ -0,0 +1,153 @@
+// Copyright 2016-2017 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//     http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffWithJitterMonotonicallyIncreasesUntilCap(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts: 10,
+		BaseBackoff: time.Second,
+		MaxBackoff:  30 * time.Second,
+		Jitter:      0,
+	}
+
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 10; attempt++ {
+		backoff := backoffWithJitter(policy, attempt)
+		assert.True(t, backoff >= prev, "backoff should not decrease between attempt %d and %d", attempt-1, attempt)
+		assert.True(t, backoff <= policy.MaxBackoff, "backoff should never exceed MaxBackoff")
+		prev = backoff
+	}
+}
+
+func TestBackoffWithJitterStaysWithinBounds(t *testing.T) {
+	policy := &RetryPolicy{
+		MaxAttempts: 10,
+		BaseBackoff: time.Second,
+		MaxBackoff:  time.Minute,
+		Jitter:      0.5,
+	}
+
+	unjittered := time.Second * time.Duration(1<<3) // attempt 4: base * 2^3
+	lower := time.Duration(float64(unjittered) * 0.5)
+	upper := time.Duration(float64(unjittered) * 1.5)
+
+	for i := 0; i < 100; i++ {
+		backoff := backoffWithJitter(policy, 4)
+		assert.True(t, backoff >= lower, "jittered backoff %v should not fall below %v", backoff, lower)
+		assert.True(t, backoff <= upper, "jittered backoff %v should not exceed %v", backoff, upper)
+	}
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressTracksRetries() {
+	suite.deployment.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Second,
+		Jitter:      0,
+	}
+
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+	assert.Len(suite.T(), suite.deployment.RetryableFailures, 1, "The failed instance should be tracked for retry")
+	assert.Exactly(suite.T(), 1, suite.deployment.RetryableFailures[0].Attempt, "First failure should be attempt 1")
+	assert.Empty(suite.T(), suite.deployment.PermanentFailures, "Instance should not yet be a permanent failure")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressPromotesToPermanentFailure() {
+	suite.deployment.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 2,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Second,
+		Jitter:      0,
+	}
+
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	time.Sleep(5 * time.Millisecond)
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	time.Sleep(5 * time.Millisecond)
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+	assert.Empty(suite.T(), suite.deployment.RetryableFailures, "Instance should no longer be tracked for retry")
+	assert.Len(suite.T(), suite.deployment.PermanentFailures, 1, "Instance should have been promoted to a permanent failure")
+
+	replicaFailure := suite.deployment.GetCondition(DeploymentReplicaFailure)
+	assert.NotNil(suite.T(), replicaFailure, "ReplicaFailure condition should be set")
+	assert.Exactly(suite.T(), ConditionTrue, replicaFailure.Status, "ReplicaFailure condition should be True once a failure becomes permanent")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressDoesNotAdvanceRetryBeforeBackoffElapses() {
+	suite.deployment.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 5,
+		BaseBackoff: time.Hour,
+		MaxBackoff:  time.Hour,
+		Jitter:      0,
+	}
+
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+	assert.Len(suite.T(), suite.deployment.RetryableFailures, 1, "Instance should still be tracked for retry")
+	assert.Exactly(suite.T(), 1, suite.deployment.RetryableFailures[0].Attempt, "Attempt should not advance again before the backoff elapses")
+}
+
+func (suite *DeploymentTestSuite) TestUpdateDeploymentToInProgressDoesNotReRetryPermanentFailures() {
+	suite.deployment.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 1,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Second,
+		Jitter:      0,
+	}
+
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	time.Sleep(5 * time.Millisecond)
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	time.Sleep(5 * time.Millisecond)
+	err := suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+
+	assert.Nil(suite.T(), err, "Unexpected error when setting deployment in progress")
+	assert.Len(suite.T(), suite.deployment.PermanentFailures, 1, "Instance should be recorded as a permanent failure exactly once, not duplicated")
+	assert.Empty(suite.T(), suite.deployment.RetryableFailures, "Permanently failed instance should not be re-admitted for retry")
+}
+
+func (suite *DeploymentTestSuite) TestDueForRetry() {
+	suite.deployment.RetryPolicy = &RetryPolicy{
+		MaxAttempts: 5,
+		BaseBackoff: time.Hour,
+		MaxBackoff:  time.Hour,
+		Jitter:      0,
+	}
+
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	assert.Empty(suite.T(), suite.deployment.DueForRetry(time.Now()), "Failure should not be due for retry before its backoff elapses")
+
+	future := time.Now().Add(2 * time.Hour)
+	due := suite.deployment.DueForRetry(future)
+	assert.Len(suite.T(), due, 1, "Failure should be due for retry once its backoff has elapsed")
+	assert.Exactly(suite.T(), aws.StringValue(suite.failures[0].Arn), aws.StringValue(due[0].Arn), "Due failure should match the tracked instance")
+}
+
+func (suite *DeploymentTestSuite) TestDueForRetryWithoutRetryPolicy() {
+	suite.deployment.UpdateDeploymentToInProgress(desiredTaskCount, suite.failures, nil)
+	assert.Empty(suite.T(), suite.deployment.DueForRetry(time.Now().Add(time.Hour)), "DueForRetry should be a no-op when no RetryPolicy is set")
+}